@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	drive "google.golang.org/api/drive/v3"
+)
+
+// dirCacheFile persists the logical path to Drive folder ID mapping to disk
+// so lookups survive process restarts.
+const dirCacheFile = "./tmp/drive_dir_cache.json"
+
+// dirCache resolves a logical directory path, e.g. "sunlapse/2024/06/10", to
+// a Drive folder ID, creating folders only when they do not already exist
+// rather than on every call. Drive allows multiple folders with the same
+// name and parent, so without this cache repeated runs produce duplicate
+// folder trees.
+type dirCache struct {
+	service *drive.Service
+	mu      sync.Mutex
+	ids     map[string]string
+}
+
+// newDirCache loads any previously cached path to folder ID mappings from
+// disk and returns a dirCache backed by service.
+// It returns any errors encountered while loading the cache.
+func newDirCache(service *drive.Service) (*dirCache, error) {
+	ids := map[string]string{}
+
+	data, err := ioutil.ReadFile(dirCacheFile)
+	if err == nil {
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &dirCache{service: service, ids: ids}, nil
+}
+
+// save persists the current path to folder ID mappings to disk.
+// It returns any errors encountered while writing.
+func (c *dirCache) save() error {
+	data, err := json.Marshal(c.ids)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dirCacheFile, data, 0644)
+}
+
+// getOrCreateDriveDir resolves a logical path, e.g. "sunlapse/2024/06/10",
+// to a Drive folder ID, walking and creating each path segment under its
+// parent as needed. Resolved IDs are cached both in memory and on disk, so
+// repeated calls for the same path return the same folder rather than
+// creating a new one.
+// It returns the folder ID of the final path segment and any errors
+// encountered.
+func (c *dirCache) getOrCreateDriveDir(path string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if id, ok := c.ids[path]; ok {
+		return id, nil
+	}
+
+	parent := "root"
+	built := ""
+
+	for _, segment := range strings.Split(path, "/") {
+		if built == "" {
+			built = segment
+		} else {
+			built = fmt.Sprintf("%s/%s", built, segment)
+		}
+
+		if id, ok := c.ids[built]; ok {
+			parent = id
+			continue
+		}
+
+		id, err := c.resolveOrCreateDir(segment, parent)
+		if err != nil {
+			return "", err
+		}
+
+		c.ids[built] = id
+		parent = id
+	}
+
+	if err := c.save(); err != nil {
+		return "", err
+	}
+
+	return parent, nil
+}
+
+// resolveOrCreateDir finds the Drive folder named name under parent,
+// creating it if it does not already exist.
+// It returns the folder's ID and any errors encountered.
+func (c *dirCache) resolveOrCreateDir(name, parent string) (string, error) {
+	cLog := log.WithFields(log.Fields{
+		"name":   name,
+		"parent": parent,
+	})
+
+	query := fmt.Sprintf(
+		"name='%s' and mimeType='application/vnd.google-apps.folder' and '%s' in parents and trashed=false",
+		name, parent)
+
+	list, err := c.service.Files.List().Q(query).Fields("files(id)").Do()
+	if err != nil {
+		cLog.Errorf("Error listing drive directory: %s", err)
+		return "", err
+	}
+
+	if len(list.Files) > 0 {
+		return list.Files[0].Id, nil
+	}
+
+	cLog.Infof("Creating %s drive directory", name)
+
+	directory := &drive.File{
+		Name:     name,
+		MimeType: "application/vnd.google-apps.folder",
+		Parents:  []string{parent},
+	}
+
+	file, err := c.service.Files.Create(directory).Do()
+	if err != nil {
+		cLog.Errorf("Error creating drive directory: %s", err)
+		return "", err
+	}
+
+	return file.Id, nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	drive "google.golang.org/api/drive/v3"
+)
+
+// driveHTTPClient is the OAuth authenticated http.Client backing
+// driveService, kept at package level so the resumable upload path can talk
+// to the Drive upload endpoints directly rather than through drive.Service.
+var driveHTTPClient *http.Client
+
+// driveStorage implements Storage via the Google Drive API. This is the
+// original (and default) backend used by sunlapse.
+type driveStorage struct {
+	service *drive.Service
+	dirs    *dirCache
+}
+
+// newDriveStorage authenticates with Google Drive and returns a driveStorage.
+// It returns an error if authentication or loading the directory cache
+// fails.
+func newDriveStorage() (*driveStorage, error) {
+	service, err := getDriveService()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := newDirCache(service)
+	if err != nil {
+		return nil, err
+	}
+
+	return &driveStorage{service: service, dirs: dirs}, nil
+}
+
+// driveFolderPath returns the logical Drive directory a bucket's files are
+// stored under. bucket is "<source>/<year>-<month>-<day>", e.g.
+// "front-door/2024-06-10" becomes "sunlapse/front-door/2024/06/10". The
+// source and date components are joined explicitly rather than by
+// replacing dashes, since source names are free-form and may themselves
+// contain dashes.
+func driveFolderPath(bucket string) string {
+	source, dateStr, _ := strings.Cut(bucket, "/")
+
+	return path.Join(append([]string{"sunlapse", source}, strings.Split(dateStr, "-")...)...)
+}
+
+// Put uploads r to Drive as a file named name, tagging it with bucket and
+// placing it in the Drive directory corresponding to bucket so it can be
+// found again by Get and Delete. Readers that support io.ReaderAt are
+// uploaded in chunks via the resumable upload protocol, so a crash mid
+// upload resumes rather than restarting; other readers fall back to a
+// single-shot upload.
+// It returns any errors encountered during upload.
+func (s *driveStorage) Put(bucket, name string, r io.Reader, contentType string, size int64) error {
+	parent, err := s.dirs.getOrCreateDriveDir(driveFolderPath(bucket))
+	if err != nil {
+		return err
+	}
+
+	readerAt, ok := r.(io.ReaderAt)
+	if !ok {
+		_, err := s.service.Files.Create(&drive.File{
+			Name:        name,
+			Description: bucket,
+			Parents:     []string{parent},
+		}).Media(r).Do()
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", bucket, name)
+	_, err = s.uploadResumable(key, name, bucket, parent, readerAt, size, contentType)
+	return err
+}
+
+// Get downloads the file named name previously uploaded for bucket.
+// It returns an io.ReadCloser and any errors encountered.
+func (s *driveStorage) Get(bucket, name string) (io.ReadCloser, error) {
+	id, err := s.findFileID(bucket, name)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.service.Files.Get(id).Download()
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// Delete removes the file named name previously uploaded for bucket.
+// It returns any errors encountered during removal.
+func (s *driveStorage) Delete(bucket, name string) error {
+	id, err := s.findFileID(bucket, name)
+	if err != nil {
+		return err
+	}
+
+	return s.service.Files.Delete(id).Do()
+}
+
+// Type returns "drive".
+func (s *driveStorage) Type() string {
+	return "drive"
+}
+
+// findFileID looks up the Drive file ID of a file previously uploaded by
+// Put, matching on name and the bucket tag stored in its description.
+// It returns the file ID and any errors encountered, including if no
+// matching file is found.
+func (s *driveStorage) findFileID(bucket, name string) (string, error) {
+	query := fmt.Sprintf("name='%s' and trashed=false", name)
+
+	list, err := s.service.Files.List().Q(query).Fields("files(id, description)").Do()
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range list.Files {
+		if file.Description == bucket {
+			return file.Id, nil
+		}
+	}
+
+	return "", fmt.Errorf("file not found in drive storage: %s/%s", bucket, name)
+}
+
+// tokenFromFile loads a provided file path, and unmarshals it into an oauth
+// token.
+// It returns an *oauth2.Token and any errors encountered.
+func tokenFromFile(path string) (*oauth2.Token, error) {
+	cLog := log.WithFields(log.Fields{
+		"path": path,
+	})
+
+	// Load the token file
+	file, err := os.Open(path)
+	defer file.Close()
+	if err != nil {
+		cLog.Errorf("Error loading OAuth token from file: %s", err)
+		return nil, err
+	}
+
+	// Create the token object
+	token := &oauth2.Token{}
+
+	// Unmarshal the JSON string into the oauth token
+	err = json.NewDecoder(file).Decode(token)
+	if err != nil {
+		cLog.Errorf("Error Decoding OAuth token from file: %s", err)
+		return nil, err
+	}
+
+	// Return the token
+	return token, nil
+}
+
+// saveToken takes a path and an oauth2.Token and saves it to the path specified
+// It returns any errors encountered while saving.
+func saveToken(path string, token *oauth2.Token) error {
+	cLog := log.WithFields(log.Fields{
+		"path": path,
+	})
+
+	// Open the file handle
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	defer file.Close()
+	if err != nil {
+		cLog.Errorf("Error opening OAuth token file for saving: %s", err)
+		return err
+	}
+
+	// Marshal the token and save it into the file
+	err = json.NewEncoder(file).Encode(token)
+	if err != nil {
+		cLog.Errorf("Error savin")
+		return err
+	}
+
+	return nil
+}
+
+// tokenFromWeb takes an oauth2.Config prompts the user to manually generate
+// an authentication token in their browser.
+// It returns an *oauth2.Token and any errors encountered.
+func tokenFromWeb(config *oauth2.Config) (*oauth2.Token, error) {
+	// Generate the URL for manually authenticating via OAuth
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+
+	// Print a prompt indicating to go to the URL and authenticate
+	fmt.Printf("Go to the following link in your browser then type the "+
+		"authorization code: \n%v\n", authURL)
+
+	// Scan for the user input of the authentication code
+	var authCode string
+	_, err := fmt.Scan(&authCode)
+	if err != nil {
+		log.Errorf("Error loading input OAuth token: %s", err)
+		return nil, err
+	}
+
+	// Exchange authentication information using the code
+	token, err := config.Exchange(oauth2.NoContext, authCode)
+	if err != nil {
+		log.Errorf("Error authenticating using input Oauth token: %s", err)
+		return nil, err
+	}
+
+	// Return the authentication token
+	return token, nil
+}
+
+// getOauthClient takes a Google OAuth configuration and returns a generated
+// authenticated http Client.
+// It returns an authenticated http.Client and any errors encountered.
+func getOauthClient(config *oauth2.Config) (*http.Client, error) {
+	// Set the token file to the specified config value
+	tokenFile := conf.DriveTokenFile
+
+	// Attempt to load an existing token from the file
+	token, err := tokenFromFile(tokenFile)
+	if err != nil {
+		// If not found, attempt to generate a new one from the web
+		token, err = tokenFromWeb(config)
+		if err != nil {
+			// If unable to generate, error
+			log.Errorf("Error generating oauth token from web: %s", err)
+			return nil, err
+		}
+
+		// Otherwise, save the generated token for later use
+		saveToken(tokenFile, token)
+	}
+
+	// Return an oauth client authenticated with the token, keeping a package
+	// level reference for the resumable upload path, which talks to the
+	// Drive upload endpoints directly rather than through drive.Service.
+	client := config.Client(context.Background(), token)
+	driveHTTPClient = client
+
+	return client, nil
+}
+
+// getDriveService creates an authenticated client for interacting with the
+// Google Drive API.
+// It returns a *drive.Service and any errors encountered.
+func getDriveService() (*drive.Service, error) {
+	// Load the client secret
+	secret, err := ioutil.ReadFile(conf.DriveSecretFile)
+	if err != nil {
+		log.Errorf("Error encountered loading Google OAuth secret: %s", err)
+		return nil, err
+	}
+
+	// Generate a Google OAuth configuration from the JSON byte slice
+	// Uses drive.DriveFileScope
+	config, err := google.ConfigFromJSON(secret, drive.DriveFileScope)
+	if err != nil {
+		log.Errorf("Error encountered creating Google Oauth config: %s", err)
+		return nil, err
+	}
+
+	// Get an OAuth authenticated http.Client
+	client, err := getOauthClient(config)
+	if err != nil {
+		log.Errorf("Error encountered creating Oauth client: %s", err)
+		return nil, err
+	}
+
+	// Create the Drive Service using the authenticated http.Client
+	service, err := drive.New(client)
+	if err != nil {
+		log.Errorf("Error encountered creating Drive Service: %s", err)
+		return nil, err
+	}
+
+	// Return the Drive Service
+	return service, nil
+}
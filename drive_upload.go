@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// driveUploadChunkSize is the size of each chunk sent to Drive's
+	// resumable upload endpoint. Google requires chunk sizes to be a
+	// multiple of 256 KiB; 8 MiB keeps individual requests small enough to
+	// retry cheaply on a flaky home connection.
+	driveUploadChunkSize = 8 * 1024 * 1024
+
+	// driveUploadSessionFile persists in-progress resumable upload session
+	// URIs, keyed by bucket/name, so a crash mid-upload can resume instead
+	// of restarting from byte zero.
+	driveUploadSessionFile = "./tmp/drive_upload_sessions.json"
+
+	// maxTries is the number of attempts made for a single chunk upload
+	// before giving up.
+	maxTries = 5
+)
+
+// driveUploadSession tracks a resumable upload in progress: the session URI
+// Drive issued, and how many bytes it has confirmed receiving so far.
+type driveUploadSession struct {
+	URI  string `json:"uri"`
+	Sent int64  `json:"sent"`
+}
+
+// errSessionGone indicates Drive no longer recognizes a resumable session
+// URI (404/410), typically because it expired or was already finalized. The
+// caller must discard the session and start a new one rather than retry it.
+var errSessionGone = errors.New("drive resumable upload session is no longer valid")
+
+// loadDriveUploadSessions reads persisted resumable session state from disk.
+// It returns an empty map if no sessions have been persisted yet.
+func loadDriveUploadSessions() (map[string]driveUploadSession, error) {
+	sessions := map[string]driveUploadSession{}
+
+	data, err := ioutil.ReadFile(driveUploadSessionFile)
+	if os.IsNotExist(err) {
+		return sessions, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// saveDriveUploadSessions persists resumable session state to disk.
+// It returns any errors encountered while writing.
+func saveDriveUploadSessions(sessions map[string]driveUploadSession) error {
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(driveUploadSessionFile, data, 0644)
+}
+
+// uploadResumable uploads size bytes read from r, named name and tagged
+// with the given description, to Drive using the v3 resumable upload
+// protocol. The session URI and progress are persisted to disk under key so
+// a crash mid-upload resumes from the last acknowledged byte rather than
+// restarting.
+// It returns the created file's Drive ID and any errors encountered.
+func (s *driveStorage) uploadResumable(key, name, description, parent string, r io.ReaderAt,
+	size int64, contentType string) (string, error) {
+	cLog := log.WithFields(log.Fields{
+		"name": name,
+		"size": size,
+	})
+
+	sessions, err := loadDriveUploadSessions()
+	if err != nil {
+		return "", err
+	}
+
+	session, resuming := sessions[key]
+	if !resuming {
+		uri, err := s.startResumableSession(name, description, parent, contentType)
+		if err != nil {
+			cLog.Errorf("Error starting resumable upload session: %s", err)
+			return "", err
+		}
+
+		session = driveUploadSession{URI: uri}
+	} else {
+		cLog.Infof("Resuming upload from byte %d", session.Sent)
+	}
+
+	var fileID string
+	for session.Sent < size {
+		end := session.Sent + driveUploadChunkSize
+		if end > size {
+			end = size
+		}
+
+		chunk := make([]byte, end-session.Sent)
+		if _, err := r.ReadAt(chunk, session.Sent); err != nil && err != io.EOF {
+			return "", err
+		}
+
+		received, id, err := s.putChunk(session.URI, chunk, session.Sent, end, size)
+		if errors.Is(err, errSessionGone) {
+			cLog.Warnf("Resumable session expired, starting a new one: %s", err)
+
+			uri, startErr := s.startResumableSession(name, description, parent, contentType)
+			if startErr != nil {
+				cLog.Errorf("Error starting resumable upload session: %s", startErr)
+				return "", startErr
+			}
+
+			session = driveUploadSession{URI: uri}
+
+			sessions[key] = session
+			if err := saveDriveUploadSessions(sessions); err != nil {
+				return "", err
+			}
+
+			continue
+		} else if err != nil {
+			cLog.Errorf("Error uploading chunk: %s", err)
+			return "", err
+		}
+
+		session.Sent = received
+		fileID = id
+
+		sessions[key] = session
+		if err := saveDriveUploadSessions(sessions); err != nil {
+			return "", err
+		}
+	}
+
+	delete(sessions, key)
+	if err := saveDriveUploadSessions(sessions); err != nil {
+		return "", err
+	}
+
+	return fileID, nil
+}
+
+// startResumableSession asks Drive to begin a new resumable upload and
+// returns the session URI subsequent chunks are PUT to.
+// It returns any errors encountered while starting the session.
+func (s *driveStorage) startResumableSession(name, description, parent, contentType string) (string, error) {
+	metadata, err := json.Marshal(map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"parents":     []string{parent},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest(http.MethodPost,
+		"https://www.googleapis.com/upload/drive/v3/files?uploadType=resumable",
+		bytes.NewReader(metadata))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	request.Header.Set("X-Upload-Content-Type", contentType)
+
+	response, err := driveHTTPClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error starting resumable upload session: %s", response.Status)
+	}
+
+	uri := response.Header.Get("Location")
+	if uri == "" {
+		return "", errors.New("drive did not return a resumable upload session uri")
+	}
+
+	return uri, nil
+}
+
+// putChunk uploads a single chunk of bytes start-end (of a total upload of
+// size total) to the given resumable session URI, retrying 5xx responses
+// and network errors with exponential backoff up to maxTries. A 404/410
+// means the session URI itself is no longer valid (expired or already
+// finalized), which is reported as errSessionGone so the caller starts a
+// fresh session instead of retrying a dead one.
+// It returns the number of bytes Drive has now received, the created file's
+// ID (populated once the final chunk succeeds), and any error encountered.
+func (s *driveStorage) putChunk(uri string, chunk []byte, start, end, total int64) (int64, string, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxTries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+			log.Debugf("Retrying chunk upload in %s (attempt %d/%d)", backoff, attempt+1, maxTries)
+			time.Sleep(backoff)
+		}
+
+		request, err := http.NewRequest(http.MethodPut, uri, bytes.NewReader(chunk))
+		if err != nil {
+			return start, "", err
+		}
+		request.ContentLength = int64(len(chunk))
+		request.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, total))
+
+		response, err := driveHTTPClient.Do(request)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		switch {
+		case response.StatusCode == 308:
+			// "Resume Incomplete" - Drive tells us how many bytes it
+			// actually has via the Range header. Drive omits the header
+			// entirely when it has persisted none of this request's bytes,
+			// so default to no progress rather than assuming the chunk was
+			// fully stored.
+			received := start
+
+			var rangeEnd int64
+			if _, err := fmt.Sscanf(response.Header.Get("Range"), "bytes=0-%d", &rangeEnd); err == nil {
+				received = rangeEnd + 1
+			}
+
+			return received, "", nil
+		case response.StatusCode == http.StatusOK || response.StatusCode == http.StatusCreated:
+			var file struct {
+				ID string `json:"id"`
+			}
+
+			if err := json.Unmarshal(body, &file); err != nil {
+				return end, "", err
+			}
+
+			return end, file.ID, nil
+		case response.StatusCode >= 500:
+			lastErr = fmt.Errorf("server error uploading chunk: %s", response.Status)
+			continue
+		case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+			return start, "", fmt.Errorf("%w: %s", errSessionGone, response.Status)
+		default:
+			return start, "", fmt.Errorf("unexpected status uploading chunk: %s", response.Status)
+		}
+	}
+
+	return start, "", fmt.Errorf("giving up after %d attempts: %s", maxTries, lastErr)
+}
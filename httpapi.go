@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runHTTPServer starts the embedded control/status HTTP server and blocks
+// until it exits.
+func runHTTPServer(addr string) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/capture", handleCapture)
+	mux.HandleFunc("/summarize", handleSummarize)
+	mux.HandleFunc("/images/", handleImage)
+	mux.HandleFunc("/videos/", handleVideo)
+	mux.HandleFunc("/metrics", handleMetrics)
+
+	log.Infof("Starting HTTP server on %s", addr)
+
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Fatalf("Error running HTTP server: %s", err)
+	}
+}
+
+// safePathComponent reports whether s is safe to interpolate into a
+// filesystem path: non-empty, and free of path separators or traversal.
+func safePathComponent(s string) bool {
+	return s != "" && !strings.Contains(s, "..") && !strings.ContainsAny(s, "/\\")
+}
+
+// requestedSource resolves the Source a request refers to: the ?source=
+// query parameter if given, or the only configured Source if there is
+// exactly one.
+// It returns the Source name and whether it refers to a configured Source.
+func requestedSource(r *http.Request) (string, bool) {
+	name := r.URL.Query().Get("source")
+	if name == "" && len(sources) == 1 {
+		name = sources[0].Name
+	}
+
+	if _, ok := findSource(name); !ok {
+		return "", false
+	}
+
+	return name, true
+}
+
+// handleStatus handles GET /status, returning the current schedule and
+// capture state for the requested Source (?source=), or every configured
+// Source if none is given.
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("source") == "" {
+		all := map[string]sourceStatus{}
+		for _, source := range sources {
+			if status, ok := getStatus(source.Name); ok {
+				all[source.Name] = status
+			}
+		}
+
+		writeJSON(w, all)
+		return
+	}
+
+	name, ok := requestedSource(r)
+	if !ok {
+		http.Error(w, "unknown source", http.StatusNotFound)
+		return
+	}
+
+	status, _ := getStatus(name)
+	writeJSON(w, status)
+}
+
+// handleCapture handles POST /capture, forcing an immediate frame grab for
+// the requested Source (?source=).
+func handleCapture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := requestedSource(r)
+	if !ok {
+		http.Error(w, "unknown source", http.StatusNotFound)
+		return
+	}
+
+	controls[name].capture <- struct{}{}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleSummarize handles POST /summarize, triggering the video/archive/
+// upload pipeline for the requested Source (?source=) and date
+// (?date=2006-01-02, defaulting to today).
+func handleSummarize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := requestedSource(r)
+	if !ok {
+		http.Error(w, "unknown source", http.StatusNotFound)
+		return
+	}
+
+	date := time.Now()
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			http.Error(w, "invalid date", http.StatusBadRequest)
+			return
+		}
+
+		date = parsed
+	}
+
+	controls[name].summarize <- date
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleImage handles GET /images/{date}/{name}, streaming a single
+// captured frame for the requested Source (?source=).
+func handleImage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/images/"), "/", 2)
+	if len(parts) != 2 || !safePathComponent(parts[0]) || !safePathComponent(parts[1]) {
+		http.Error(w, "expected /images/{date}/{name}", http.StatusBadRequest)
+		return
+	}
+
+	name, ok := requestedSource(r)
+	if !ok {
+		http.Error(w, "unknown source", http.StatusNotFound)
+		return
+	}
+
+	http.ServeFile(w, r, fmt.Sprintf("./tmp/images/%s/%s/%s", name, parts[0], parts[1]))
+}
+
+// handleVideo handles GET /videos/{date}.mp4, streaming the requested
+// Source's (?source=) timelapse video for date.
+func handleVideo(w http.ResponseWriter, r *http.Request) {
+	dateStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/videos/"), ".mp4")
+	if !safePathComponent(dateStr) {
+		http.Error(w, "expected /videos/{date}.mp4", http.StatusBadRequest)
+		return
+	}
+
+	name, ok := requestedSource(r)
+	if !ok {
+		http.Error(w, "unknown source", http.StatusNotFound)
+		return
+	}
+
+	http.ServeFile(w, r, fmt.Sprintf("./tmp/videos/%s/%s.mp4", name, dateStr))
+}
+
+// handleMetrics handles GET /metrics, rendering current counters in
+// Prometheus text exposition format.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, metricsStore.render())
+}
+
+// writeJSON writes v to w as an indented JSON document.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Errorf("Error encoding JSON response: %s", err)
+	}
+}
@@ -6,23 +6,17 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
-	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
-	"github.com/kelvins/sunrisesunset"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	drive "google.golang.org/api/drive/v3"
 )
 
 // Type config represents configuration of the sunlapse application, with struct
@@ -33,18 +27,29 @@ type config struct {
 	LogLevel        string  `default:"info"`
 	DriveTokenFile  string  `default:"drive_token.json"`
 	DriveSecretFile string  `default:"drive_client_secret.json"`
-	Endpoint        string  `required:"true"`
-	Latitude        float64 `required:"true"`
-	Longitude       float64 `required:"true"`
-	Offset          float64 `required:"true"`
+	Endpoint        string  `default:""`
+	Latitude        float64 `default:"0"`
+	Longitude       float64 `default:"0"`
+	Offset          float64 `default:"0"`
+	Storage         string  `default:"drive"`
+	LocalStorePath  string  `default:"./tmp/storage"`
+	S3Bucket        string  `default:""`
+	S3Region        string  `default:"us-east-1"`
+	SourcesFile     string  `default:"sources.yaml"`
+	ScheduleMode    string  `default:"civil"`
+	Start           string  `default:"06:00"`
+	End             string  `default:"20:00"`
+	HTTPAddr        string  `default:":8080" envconfig:"HTTP_ADDR"`
 }
 
-// Package level config, http.Client, Drive service, and if it is being used
+// Package level config, http.Client, Storage backend, capture Sources, and
+// if it is being used
 var (
-	conf         config
-	client       http.Client
-	localMode    bool
-	driveService *drive.Service
+	conf      config
+	client    http.Client
+	localMode bool
+	store     Storage
+	sources   []Source
 )
 
 func init() {
@@ -54,6 +59,22 @@ func init() {
 		log.Fatalf("Error parsing environment variables: %s", err)
 	}
 
+	// Latitude/Longitude/Offset are only used to compute sun-relative
+	// schedules, so "manual" mode (which ignores the sun entirely) is
+	// exempt from requiring them.
+	if conf.ScheduleMode != "manual" {
+		var missing []string
+		for _, name := range []string{"SUNLAPSE_LATITUDE", "SUNLAPSE_LONGITUDE", "SUNLAPSE_OFFSET"} {
+			if _, ok := os.LookupEnv(name); !ok {
+				missing = append(missing, name)
+			}
+		}
+
+		if len(missing) > 0 {
+			log.Fatalf("Missing required environment variables: %s", strings.Join(missing, ", "))
+		}
+	}
+
 	// Logging options
 	log.SetFormatter(&log.JSONFormatter{})
 	log.SetOutput(os.Stdout)
@@ -71,10 +92,10 @@ func init() {
 		log.SetLevel(log.InfoLevel)
 	}
 
-	// Authentication with Google Drive
-	driveService, err = getDriveService()
+	// Initialize the configured Storage backend
+	store, err = getStorage()
 	if err != nil {
-		log.Errorf("Error authenticating with Google Drive: %s", err)
+		log.Errorf("Error initializing %s storage backend: %s", conf.Storage, err)
 		log.Errorf("Running in local-only mode")
 		localMode = true
 	}
@@ -95,152 +116,293 @@ func init() {
 			log.Fatal(err)
 		}
 	}
+
+	// Load the configured capture Sources
+	sources, err = loadSources(conf.SourcesFile)
+	if err != nil {
+		log.Fatalf("Error loading capture sources: %s", err)
+	}
+}
+
+// summaryJob identifies a single Source's finished day of capture, ready to
+// be turned into a video and image archive.
+type summaryJob struct {
+	Source string
+	Date   time.Time
 }
 
 func main() {
-	// Channel and goroutine for "summarizing" the day
-	summaryChannel := make(chan time.Time)
-	go func() {
-		for summaryTime := range summaryChannel {
-			// Create the timelapse video
-			log.Info("Creating video")
-
-			err := createVideo(summaryTime)
+	// Channel and goroutine for "summarizing" each source's day
+	summaryChannel := make(chan summaryJob)
+	go runSummaryWorker(summaryChannel)
+
+	// One capture goroutine per configured Source, each with its own
+	// control channels so the HTTP API can reach it
+	for _, source := range sources {
+		control := &sourceControl{
+			capture:   make(chan struct{}),
+			summarize: make(chan time.Time),
+		}
+		controls[source.Name] = control
+
+		// Seed the counters so /metrics reports source at startup
+		// instead of only after its first recorded event.
+		metricsStore.register(source.Name)
+
+		go captureLoop(source, summaryChannel, control)
+	}
+
+	// HTTP control/status API
+	go runHTTPServer(conf.HTTPAddr)
+
+	// Indefinitely sleep the main goroutine
+	for {
+		time.Sleep(time.Second * 30)
+	}
+}
+
+// runSummaryWorker drains summaryChannel, and for each job creates a video
+// and image archive from that source's images, uploads them through the
+// configured Storage backend, and then deletes the source images.
+func runSummaryWorker(summaryChannel <-chan summaryJob) {
+	for job := range summaryChannel {
+		cLog := log.WithFields(log.Fields{
+			"source": job.Source,
+		})
+
+		// Create the timelapse video
+		cLog.Info("Creating video")
+
+		videoStart := time.Now()
+		err := createVideo(job.Source, job.Date)
+		metricsStore.recordFfmpegDuration(job.Source, time.Since(videoStart))
+
+		if err != nil {
+			cLog.Errorf("Error creating video: %s", err)
+			updateStatus(job.Source, func(s *sourceStatus) { s.LastError = err.Error() })
+		} else {
+			// If able to make video, make archive
+			cLog.Info("Creating image archive")
+
+			err = archiveImages(job.Source, job.Date)
 			if err != nil {
-				log.Errorf("Error creating video: %s", err)
+				cLog.Errorf("Error creating image archive: %s", err)
+				updateStatus(job.Source, func(s *sourceStatus) { s.LastError = err.Error() })
 			} else {
-				// If able to make video, make archive
-				log.Info("Creating image archive")
-
-				err = archiveImages(summaryTime)
-				if err != nil {
-					log.Errorf("Error creating image archive: %s", err)
-				} else {
-					// If able to make archive, delete images
-					log.Info("Deleting images")
+				// If able to archive and a storage backend is available,
+				// upload the video and archive before deleting images
+				if !localMode {
+					cLog.Info("Uploading video and archive")
 
-					err = deleteImages(summaryTime)
+					uploaded, err := uploadSummary(job.Source, job.Date)
 					if err != nil {
-						log.Errorf("Error deleting image archive: %s", err)
+						cLog.Errorf("Error uploading summary: %s", err)
+						updateStatus(job.Source, func(s *sourceStatus) { s.LastError = err.Error() })
+					} else {
+						metricsStore.recordUpload(job.Source, uploaded)
 					}
 				}
+
+				// If able to make archive, delete images
+				cLog.Info("Deleting images")
+
+				err = deleteImages(job.Source, job.Date)
+				if err != nil {
+					cLog.Errorf("Error deleting image archive: %s", err)
+					updateStatus(job.Source, func(s *sourceStatus) { s.LastError = err.Error() })
+				} else {
+					updateStatus(job.Source, func(s *sourceStatus) { s.LastSummary = time.Now() })
+				}
 			}
 		}
-	}()
-
-	// Ticker and routine for taking images and checking if it is daytime
-	ticker := time.NewTicker(time.Second * time.Duration(conf.Period))
-	go func() {
-		// Initially compute daytime
-		sunrise, sunset, err := getSunriseSunset(conf.Latitude, conf.Longitude,
-			conf.Offset, time.Now())
+	}
+}
+
+// captureImage grabs a single image from source and writes it to dir as the
+// next sequentially numbered frame, recording the outcome in source's
+// status and metrics.
+func captureImage(cLog *log.Entry, source Source, dir, ext string, imageIndex *int) {
+	cLog.Info("Getting image")
+
+	image, err := getImage(source)
+	if err != nil {
+		// If you can't get the image, error and wait for next loop
+		cLog.Errorf("Error while getting image: %s", err)
+		metricsStore.recordCapture(source.Name, false)
+		updateStatus(source.Name, func(s *sourceStatus) { s.LastError = err.Error() })
+		return
+	}
+
+	// Otherwise, save the image
+	cLog.Info("Writing image")
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s/image_%05d.%s", dir, *imageIndex, ext), image, 0755)
+	if err != nil {
+		// If you can't save the image, don't increment the counter
+		cLog.Errorf("Error while writing image: %s", err)
+		metricsStore.recordCapture(source.Name, false)
+		updateStatus(source.Name, func(s *sourceStatus) { s.LastError = err.Error() })
+		return
+	}
+
+	// Otherwise, increment the image counter
+	*imageIndex += 1
+
+	metricsStore.recordCapture(source.Name, true)
+	updateStatus(source.Name, func(s *sourceStatus) {
+		s.ImageIndex = *imageIndex
+		s.LastCapture = time.Now()
+	})
+}
+
+// captureLoop runs the capture schedule for a single Source: computing the
+// day's capture windows, grabbing an image on each tick while inside one of
+// them, and signalling summaryChannel once per day after the last window
+// closes. control lets the HTTP API force an out-of-band capture or
+// summary for this Source.
+func captureLoop(source Source, summaryChannel chan<- summaryJob, control *sourceControl) {
+	cLog := log.WithFields(log.Fields{
+		"source": source.Name,
+	})
+
+	period := source.Period
+	if period == 0 {
+		period = conf.Period
+	}
+
+	ext := source.Format
+	if ext == "" {
+		ext = "jpg"
+	}
+
+	scheduler := &Scheduler{
+		Mode:      conf.ScheduleMode,
+		Latitude:  conf.Latitude,
+		Longitude: conf.Longitude,
+		Offset:    conf.Offset,
+		Start:     conf.Start,
+		End:       conf.End,
+	}
+
+	// Get current time, directory name, and today's capture windows
+	curTime := time.Now()
+	currentDate := curTime.Format("2006-01-02")
+	dir := fmt.Sprintf("./tmp/images/%s/%s", source.Name, currentDate)
+
+	windows, err := scheduler.Windows(curTime)
+	if err != nil {
+		cLog.Fatalf("Error calculating capture windows: %s", err)
+	}
+
+	for _, w := range windows {
+		cLog.Infof("Window: %s - %s", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+	}
+
+	// Check if it is within a capture window at time of run
+	wasCapturing := inWindows(windows, curTime)
+	if wasCapturing {
+		cLog.Debug("It is currently within a capture window")
+
+		err := createDirectory(dir)
 		if err != nil {
-			log.Fatalf("Error calculating Sunrise/Sunset: %s", err)
+			cLog.Fatal(err)
 		}
+	} else {
+		cLog.Debug("It is not currently within a capture window")
+	}
 
-		// Get current time and directory name
-		curTime := time.Now()
-		dir := fmt.Sprintf("./tmp/images/%s", curTime.Format("2006-01-02"))
+	updateStatus(source.Name, func(s *sourceStatus) {
+		s.Mode = conf.ScheduleMode
+		s.Windows = windows
+		s.IsDaytime = wasCapturing
+	})
 
-		// Check if it is daytime at time of run
-		isDaytime := inTimeSpan(sunrise, sunset, curTime)
-		if isDaytime {
-			// If it is,  make the day's directory for images
-			log.Debug("It is currently daytime")
+	// Set index for image name
+	var imageIndex int = 1
 
-			err := createDirectory(dir)
-			if err != nil {
-				log.Fatal(err)
+	ticker := time.NewTicker(time.Second * time.Duration(period))
+
+	// Range indefinitely, ticking on a schedule or on demand from the HTTP
+	// control API
+	for {
+		var curTime time.Time
+
+		select {
+		case curTime = <-ticker.C:
+		case <-control.capture:
+			cLog.Info("Forced capture requested")
+
+			if err := createDirectory(dir); err != nil {
+				cLog.Fatal(err)
 			}
-		} else {
-			// Otherwise, calculate times for tomorrow's date
-			log.Debug("It is not currently daytime")
 
-			sunrise, sunset, err = getSunriseSunset(conf.Latitude, conf.Longitude,
-				conf.Offset, time.Now().AddDate(0, 0, 1))
+			captureImage(cLog, source, dir, ext, &imageIndex)
+
+			continue
+		case date := <-control.summarize:
+			cLog.Info("Forced summary requested")
+			summaryChannel <- summaryJob{Source: source.Name, Date: date}
+
+			continue
+		}
+
+		cLog.Debugf("Current time: %s", curTime.Format(time.RFC3339))
+
+		// Recompute the day's capture windows whenever the date rolls over,
+		// resetting the image directory and index for the new day
+		dateStr := curTime.Format("2006-01-02")
+		if dateStr != currentDate {
+			cLog.Info("Calculating today's capture windows")
+
+			currentDate = dateStr
+			dir = fmt.Sprintf("./tmp/images/%s/%s", source.Name, currentDate)
+			imageIndex = 1
+
+			windows, err = scheduler.Windows(curTime)
 			if err != nil {
-				log.Fatalf("Error calculating tomorrow's Sunrise/Sunset: %s", err)
+				cLog.Fatalf("Error calculating capture windows: %s", err)
 			}
-		}
 
-		log.Infof("Sunrise: %s", sunrise.Format(time.RFC3339))
-		log.Infof("Sunset: %s", sunset.Format(time.RFC3339))
-
-		// Set index for image name
-		var imageIndex int = 1
-
-		// Range infinitely over the ticker defined in the main loop, which will
-		// tick and return a time every SUNLAPSE_PERIOD
-		for curTime := range ticker.C {
-			log.Debugf("Current sunrise: %s", sunrise.Format(time.RFC3339))
-			log.Debugf("Current sunset: %s", sunset.Format(time.RFC3339))
-			log.Debugf("Current time: %s", curTime.Format(time.RFC3339))
-
-			if inTimeSpan(sunrise, sunset, curTime) {
-				// If it's daytime, enter daytime loop
-				if !isDaytime {
-					// If this is the first instance of daytime, set the boolean to true,
-					// reset the index, and create today's image directory
-					log.Info("It has now passed sunrise")
-					isDaytime = true
-					imageIndex = 1
-					dir = fmt.Sprintf("./tmp/images/%s", curTime.Format("2006-01-02"))
-
-					err := createDirectory(dir)
-					if err != nil {
-						log.Fatal(err)
-					}
-				}
+			for _, w := range windows {
+				cLog.Infof("Window: %s - %s", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+			}
 
-				// Grab an image
-				log.Info("Getting image")
+			updateStatus(source.Name, func(s *sourceStatus) { s.Windows = windows })
+		}
 
-				image, err := getImage(conf.Endpoint)
-				if err != nil {
-					// If you can't get the image, error and wait for next loop
-					log.Errorf("Error while getting image: %s", err)
-				} else {
-					// Otherwise, save the image
-					log.Info("Writing image")
+		if inWindows(windows, curTime) {
+			// If it's within a capture window, enter the capture loop
+			if !wasCapturing {
+				// If this is the first instance of this window, set the
+				// boolean to true and create today's image directory
+				cLog.Info("Entering a capture window")
+				wasCapturing = true
+				updateStatus(source.Name, func(s *sourceStatus) { s.IsDaytime = true })
 
-					err = ioutil.WriteFile(fmt.Sprintf("%s/image_%05d.jpg",
-						dir, imageIndex), image, 0755)
-					if err != nil {
-						// If you can't save the image, don't increment the counter
-						log.Errorf("Error while writing image: %s", err)
-					} else {
-						// Otherwise, increment the image counter
-						imageIndex += 1
-					}
-				}
-			} else {
-				// Otherwise, enter the non-daytime loop
-				if isDaytime {
-					// If this is the first instance of it not being daytime, signal the
-					// summary channel with current time and set boolean to false
-					log.Info("It has now passed sunset, beginning summary routine")
-					summaryChannel <- curTime
-					isDaytime = false
-
-					// Calculate the new sunrise and sunset thresholds for tomorrow
-					log.Info("Calculating tomorrow's Sunrise/Sunset")
-
-					sunrise, sunset, err = getSunriseSunset(conf.Latitude, conf.Longitude,
-						conf.Offset, time.Now().AddDate(0, 0, 1))
-					if err != nil {
-						log.Fatalf("Error calculating tomorrow's Sunrise/Sunset: %s", err)
-					}
-				} else {
-					// If it's not the first instance, just sleep for this loop
-					log.Info("Not currently daytime, sleeping...")
+				err := createDirectory(dir)
+				if err != nil {
+					cLog.Fatal(err)
 				}
 			}
-		}
-	}()
 
-	// Indefinitely sleep the main goroutine
-	for {
-		time.Sleep(time.Second * 30)
+			captureImage(cLog, source, dir, ext, &imageIndex)
+		} else if wasCapturing {
+			// If this is the first tick outside of a window, leave the
+			// capture loop. Only signal the summary channel once the last
+			// window of the day has ended, so multi-window modes like
+			// golden-hour don't summarize between their windows.
+			cLog.Info("Exiting a capture window")
+			wasCapturing = false
+			updateStatus(source.Name, func(s *sourceStatus) { s.IsDaytime = false })
+
+			if curTime.After(windows[len(windows)-1].End) {
+				cLog.Info("Last capture window of the day has ended, beginning summary routine")
+				summaryChannel <- summaryJob{Source: source.Name, Date: curTime}
+			}
+		} else {
+			// If it's not the first instance, just sleep for this loop
+			cLog.Info("Not currently within a capture window, sleeping...")
+		}
 	}
 }
 
@@ -267,15 +429,17 @@ func createDirectory(dir string) error {
 	return nil
 }
 
-// deleteImages takes a time and deletes the images directory and all images.
+// deleteImages takes a source and a time and deletes that source's images
+// directory and all images within it for the given day.
 // It returns an error if encountered during deletion.
-func deleteImages(date time.Time) error {
-	// Form directory name: ./tmp/images/2006-01-02
-	dir := fmt.Sprintf("./tmp/images/%s", date.Format("2006-01-02"))
+func deleteImages(source string, date time.Time) error {
+	// Form directory name: ./tmp/images/<source>/2006-01-02
+	dir := fmt.Sprintf("./tmp/images/%s/%s", source, date.Format("2006-01-02"))
 
 	cLog := log.WithFields(log.Fields{
-		"date": date,
-		"dir":  dir,
+		"source": source,
+		"date":   date,
+		"dir":    dir,
 	})
 
 	// Delete all images and directory
@@ -288,20 +452,28 @@ func deleteImages(date time.Time) error {
 	return nil
 }
 
-// archiveImages takes a time and creates a tar archive with all images.
+// archiveImages takes a source and a time and creates a tar archive with all
+// of that source's images for the given day.
 // It returns an error if encountered during archival.
-func archiveImages(date time.Time) error {
-	// Form directory name: ./tmp/images/2006-01-02
-	dir := fmt.Sprintf("./tmp/images/%s", date.Format("2006-01-02"))
+func archiveImages(source string, date time.Time) error {
+	// Form directory name: ./tmp/images/<source>/2006-01-02
+	dir := fmt.Sprintf("./tmp/images/%s/%s", source, date.Format("2006-01-02"))
+	archiveDir := fmt.Sprintf("./tmp/archives/%s", source)
 
 	cLog := log.WithFields(log.Fields{
-		"date": date,
-		"dir":  dir,
+		"source": source,
+		"date":   date,
+		"dir":    dir,
 	})
 
+	if err := createDirectory(archiveDir); err != nil {
+		cLog.Error(err)
+		return err
+	}
+
 	// Create the archive file
-	archiveFile, err := os.Create(fmt.Sprintf("./tmp/archives/%s.tar.gz",
-		date.Format("2006-01-02")))
+	archiveFile, err := os.Create(fmt.Sprintf("%s/%s.tar.gz",
+		archiveDir, date.Format("2006-01-02")))
 	if err != nil {
 		cLog.Error(err)
 		return err
@@ -365,321 +537,109 @@ func archiveImages(date time.Time) error {
 	return nil
 }
 
-// createVideo takes a time and creates a timelapse video from the images.
-// It returns an error if encountered during creation.
-func createVideo(date time.Time) error {
-	// Form directory name: ./tmp/images/2006-01-02
-	dir := fmt.Sprintf("./tmp/images/%s", date.Format("2006-01-02"))
+// uploadSummary takes a source and a time and pushes that day's finished
+// video and image archive through the configured Storage backend, bucketed
+// by source and date.
+// It returns the total number of bytes uploaded and any errors encountered.
+func uploadSummary(source string, date time.Time) (int64, error) {
+	dateStr := date.Format("2006-01-02")
+	bucket := fmt.Sprintf("%s/%s", source, dateStr)
 
-	// Run ffmpeg for the time provided
-	err := runCommand("ffmpeg", "-y", "-f", "image2", "-i",
-		fmt.Sprintf("%s/image_%%05d.jpg", dir), "-r", "30", "-q:v",
-		"2", "-pix_fmt", "yuvj420p", "-vcodec", "libx264",
-		fmt.Sprintf("./tmp/videos/%s.mp4", date.Format("2006-01-02")))
+	videoPath := fmt.Sprintf("./tmp/videos/%s/%s.mp4", source, dateStr)
+	video, err := os.Open(videoPath)
 	if err != nil {
-		log.Error(err)
-		return err
+		return 0, err
 	}
+	defer video.Close()
 
-	return nil
-}
-
-// runCommand takes a command and any arguments and runs it.
-// It returns an error if encountered during execution.
-func runCommand(command string, args ...string) error {
-	cLog := log.WithFields(log.Fields{
-		"command": command,
-		"args":    args,
-	})
-
-	// Create the cmd
-	process := exec.Command(command, args...)
-
-	// Create and associate buffers for stdout and stderr
-	stdout := &bytes.Buffer{}
-	stderr := &bytes.Buffer{}
-	process.Stdout = stdout
-	process.Stderr = stderr
-
-	// Run the cmd
-	err := process.Run()
+	videoInfo, err := video.Stat()
 	if err != nil {
-		cLog.Error(string(stderr.Bytes()))
-		cLog.Debug(string(stdout.Bytes()))
-		return err
+		return 0, err
 	}
 
-	cLog.Debug(string(stderr.Bytes()))
-	cLog.Debug(string(stdout.Bytes()))
-	return nil
-}
-
-// getImage retrieves an image from a provided url.
-// It returns a byte slice with the image contents and any errors encountered.
-func getImage(url string) ([]byte, error) {
-	cLog := log.WithFields(log.Fields{
-		"url": url,
-	})
-
-	// Byte slice that will eventually hold the image contents
-	var image []byte
-
-	// Create an HTTP request based on the provided URL endpoint, returning an
-	// error if the request cannot be created.
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	err = store.Put(bucket, fmt.Sprintf("%s.mp4", dateStr), video, "video/mp4",
+		videoInfo.Size())
 	if err != nil {
-		cLog.Error(err)
-		return image, err
+		return 0, err
 	}
 
-	// Make the HTTP request with the shared http Client, returning an error if
-	// the request fails or times out.
-	response, err := client.Do(request)
+	archivePath := fmt.Sprintf("./tmp/archives/%s/%s.tar.gz", source, dateStr)
+	archive, err := os.Open(archivePath)
 	if err != nil {
-		cLog.Error(err)
-		return image, err
-	}
-
-	// Check if the status code is OK (200) and return an error if it is not.
-	if response.StatusCode != http.StatusOK {
-		cLog.Error(err)
-		return image, errors.New("non-200 status code received")
+		return 0, err
 	}
+	defer archive.Close()
 
-	// Parse the response body into a byte slice, returning an error if unable to
-	// parse.
-	image, err = ioutil.ReadAll(response.Body)
+	archiveInfo, err := archive.Stat()
 	if err != nil {
-		cLog.Error(err)
-		return image, err
-	}
-
-	// Return the byte slice.
-	return image, nil
-}
-
-// inTimeSpan takes a start and end time as well as a time and checks if the
-// time is in range.
-// It returns a boolean representing whether or not it is in range.
-func inTimeSpan(start, end, check time.Time) bool {
-	return check.After(start) && check.Before(end)
-}
-
-// getSunriseSunset takes a latitude, longitude, UTC offset, and a time, and
-// calculates the sunrise and sunset.
-// It returns time objects representing sunrise and sunset as well as any errors
-func getSunriseSunset(lat float64, long float64, offset float64,
-	date time.Time) (time.Time, time.Time, error) {
-	cLog := log.WithFields(log.Fields{
-		"latitude":  lat,
-		"longitude": long,
-		"offset":    offset,
-		"date":      time.Now(),
-	})
-
-	// Create the parameters object using provided values
-	sunCalc := sunrisesunset.Parameters{
-		Latitude:  lat,
-		Longitude: long,
-		UtcOffset: offset,
-		Date:      date,
+		return 0, err
 	}
 
-	// Calculate the sunrise and sunset
-	sunrise, sunset, err := sunCalc.GetSunriseSunset()
-	if err != nil {
-		cLog.Error(err)
-		return sunrise, sunset, err
+	if err := store.Put(bucket, fmt.Sprintf("%s.tar.gz", dateStr), archive,
+		"application/gzip", archiveInfo.Size()); err != nil {
+		return 0, err
 	}
 
-	cLog.Debugf("Before correction - Sunrise: %s, Sunset: %s",
-		sunrise.Format(time.RFC3339), sunset.Format(time.RFC3339))
-
-	// sunrisesunset returns time objects with only the hour, minute, and second
-	// values provided, leaving all others in their default state.
-	// Because of this, we must create a new time object that also includes the
-	// correct values corresponding to the date provided.
-	// e.g. 1001-01-01 15:04:03 becomes 2018-06-10 15:04:03
-	sunset = time.Date(date.Year(), date.Month(), date.Day(),
-		sunset.Hour(), sunset.Minute(), sunset.Second(), 0, time.Local)
-	sunrise = time.Date(date.Year(), date.Month(), date.Day(),
-		sunrise.Hour(), sunrise.Minute(), sunrise.Second(), 0, time.Local)
-
-	cLog.Debugf("After correction - Sunrise: %s, Sunset: %s",
-		sunrise.Format(time.RFC3339), sunset.Format(time.RFC3339))
-
-	return sunrise, sunset, nil
+	return videoInfo.Size() + archiveInfo.Size(), nil
 }
 
-// tokenFromFile loads a provided file path, and unmarshals it into an oauth
-// token.
-// It returns an *oauth2.Token and any errors encountered.
-func tokenFromFile(path string) (*oauth2.Token, error) {
-	cLog := log.WithFields(log.Fields{
-		"path": path,
-	})
+// createVideo takes a source and a time and creates a timelapse video from
+// that source's images for the given day.
+// It returns an error if encountered during creation.
+func createVideo(source string, date time.Time) error {
+	// Form directory name: ./tmp/images/<source>/2006-01-02
+	dir := fmt.Sprintf("./tmp/images/%s/%s", source, date.Format("2006-01-02"))
+	videoDir := fmt.Sprintf("./tmp/videos/%s", source)
 
-	// Load the token file
-	file, err := os.Open(path)
-	defer file.Close()
-	if err != nil {
-		cLog.Errorf("Error loading OAuth token from file: %s", err)
-		return nil, err
+	if err := createDirectory(videoDir); err != nil {
+		log.Error(err)
+		return err
 	}
 
-	// Create the token object
-	token := &oauth2.Token{}
-
-	// Unmarshal the JSON string into the oauth token
-	err = json.NewDecoder(file).Decode(token)
-	if err != nil {
-		cLog.Errorf("Error Decoding OAuth token from file: %s", err)
-		return nil, err
+	ext := "jpg"
+	if src, ok := findSource(source); ok && src.Format != "" {
+		ext = src.Format
 	}
 
-	// Return the token
-	return token, nil
-}
-
-// saveToken takes a path and an oauth2.Token and saves it to the path specified
-// It returns any errors encountered while saving.
-func saveToken(path string, token *oauth2.Token) error {
-	cLog := log.WithFields(log.Fields{
-		"path": path,
-	})
-
-	// Open the file handle
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	defer file.Close()
-	if err != nil {
-		cLog.Errorf("Error opening OAuth token file for saving: %s", err)
-		return err
-	}
-
-	// Marshal the token and save it into the file
-	err = json.NewEncoder(file).Encode(token)
+	// Run ffmpeg for the source and date provided
+	err := runCommand("ffmpeg", "-y", "-f", "image2", "-i",
+		fmt.Sprintf("%s/image_%%05d.%s", dir, ext), "-r", "30", "-q:v",
+		"2", "-pix_fmt", "yuvj420p", "-vcodec", "libx264",
+		fmt.Sprintf("%s/%s.mp4", videoDir, date.Format("2006-01-02")))
 	if err != nil {
-		cLog.Errorf("Error savin")
+		log.Error(err)
 		return err
 	}
 
 	return nil
 }
 
-// tokenFromWeb takes an oauth2.Config prompts the user to manually generate
-// an authentication token in their browser.
-// It returns an *oauth2.Token and any errors encountered.
-func tokenFromWeb(config *oauth2.Config) (*oauth2.Token, error) {
-	// Generate the URL for manually authenticating via OAuth
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-
-	// Print a prompt indicating to go to the URL and authenticate
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
-
-	// Scan for the user input of the authentication code
-	var authCode string
-	_, err := fmt.Scan(&authCode)
-	if err != nil {
-		log.Errorf("Error loading input OAuth token: %s", err)
-		return nil, err
-	}
-
-	// Exchange authentication information using the code
-	token, err := config.Exchange(oauth2.NoContext, authCode)
-	if err != nil {
-		log.Errorf("Error authenticating using input Oauth token: %s", err)
-		return nil, err
-	}
-
-	// Return the authentication token
-	return token, nil
-}
-
-// getOauthClient takes a Google OAuth configuration and returns a generated
-// authenticated http Client.
-// It returns an authenticated http.Client and any errors encountered.
-func getOauthClient(config *oauth2.Config) (*http.Client, error) {
-	// Set the token file to the specified config value
-	tokenFile := conf.DriveTokenFile
-
-	// Attempt to load an existing token from the file
-	token, err := tokenFromFile(tokenFile)
-	if err != nil {
-		// If not found, attempt to generate a new one from the web
-		token, err = tokenFromWeb(config)
-		if err != nil {
-			// If unable to generate, error
-			log.Errorf("Error generating oauth token from web: %s", err)
-			return nil, err
-		}
-
-		// Otherwise, save the generated token for later use
-		saveToken(tokenFile, token)
-	}
-
-	// Return an oauth client authenticated with the token
-	return config.Client(context.Background(), token), nil
-}
-
-// getDriveService creates an authenticated client for interacting with the
-// Google Drive API.
-// It returns a *drive.Service and any errors encountered.
-func getDriveService() (*drive.Service, error) {
-	// Load the client secret
-	secret, err := ioutil.ReadFile(conf.DriveSecretFile)
-	if err != nil {
-		log.Errorf("Error encountered loading Google OAuth secret: %s", err)
-		return nil, err
-	}
-
-	// Generate a Google OAuth configuration from the JSON byte slice
-	// Uses drive.DriveMetadataReadonlyScope
-	config, err := google.ConfigFromJSON(secret, drive.DriveFileScope)
-	if err != nil {
-		log.Errorf("Error encountered creating Google Oauth config: %s", err)
-		return nil, err
-	}
-
-	// Get an OAuth authenticated http.Client
-	client, err := getOauthClient(config)
-	if err != nil {
-		log.Errorf("Error encountered creating Oauth client: %s", err)
-		return nil, err
-	}
-
-	// Create the Drive Service using the authenticated http.Client
-	service, err := drive.New(client)
-	if err != nil {
-		log.Errorf("Error encountered creating Drive Service: %s", err)
-		return nil, err
-	}
-
-	// Return the Drive Service
-	return service, nil
-}
-
-// createDriveDirectory takes a directory name and creates the corresponding
-// directory in Google Drive.
-// It returns any errors encountered during creation.
-func createDriveDirectory(dir string) error {
+// runCommand takes a command and any arguments and runs it.
+// It returns an error if encountered during execution.
+func runCommand(command string, args ...string) error {
 	cLog := log.WithFields(log.Fields{
-		"dir": dir,
+		"command": command,
+		"args":    args,
 	})
 
-	// Create the directory File
-	// A directory is just a File with a special MimeType in Drive
-	directory := &drive.File{
-		Name:     dir,
-		MimeType: "application/vnd.google-apps.folder",
-	}
+	// Create the cmd
+	process := exec.Command(command, args...)
 
-	// Tell the drive service to create the directory
-	_, err := driveService.Files.Create(directory).Do()
+	// Create and associate buffers for stdout and stderr
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	process.Stdout = stdout
+	process.Stderr = stderr
+
+	// Run the cmd
+	err := process.Run()
 	if err != nil {
-		cLog.Errorf("Error creating drive directory: %s", err)
+		cLog.Error(string(stderr.Bytes()))
+		cLog.Debug(string(stdout.Bytes()))
 		return err
 	}
 
+	cLog.Debug(string(stderr.Bytes()))
+	cLog.Debug(string(stdout.Bytes()))
 	return nil
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ffmpegDurationBuckets are the histogram buckets, in seconds, used for the
+// sunlapse_ffmpeg_duration_seconds metric.
+var ffmpegDurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300}
+
+// sourceCounters holds the running counters for a single Source.
+type sourceCounters struct {
+	capturesAttempted int64
+	capturesSucceeded int64
+	capturesFailed    int64
+	uploadBytes       int64
+	ffmpegDurations   []float64 // seconds, one per completed createVideo run
+}
+
+// metrics holds runtime counters for every Source, exposed via the
+// /metrics endpoint in Prometheus text exposition format.
+type metrics struct {
+	mu      sync.Mutex
+	sources map[string]*sourceCounters
+}
+
+// metricsStore is the package-level metrics instance updated by the capture
+// and summary goroutines and read by the /metrics HTTP handler.
+var metricsStore = &metrics{sources: map[string]*sourceCounters{}}
+
+// counters returns source's sourceCounters, creating it if this is the
+// first time source has been recorded.
+func (m *metrics) counters(source string) *sourceCounters {
+	c, ok := m.sources[source]
+	if !ok {
+		c = &sourceCounters{}
+		m.sources[source] = c
+	}
+
+	return c
+}
+
+// register seeds source's counters at zero, so /metrics reports it (and
+// the sunlapse_daytime gauge) from startup rather than only after its
+// first recorded capture, upload, or ffmpeg run.
+func (m *metrics) register(source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counters(source)
+}
+
+// recordCapture records the outcome of a single image capture attempt.
+func (m *metrics) recordCapture(source string, success bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.counters(source)
+	c.capturesAttempted++
+
+	if success {
+		c.capturesSucceeded++
+	} else {
+		c.capturesFailed++
+	}
+}
+
+// recordUpload records bytes uploaded through the configured Storage
+// backend for source.
+func (m *metrics) recordUpload(source string, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counters(source).uploadBytes += bytes
+}
+
+// recordFfmpegDuration records how long a single createVideo run took for
+// source.
+func (m *metrics) recordFfmpegDuration(source string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.counters(source)
+	c.ffmpegDurations = append(c.ffmpegDurations, duration.Seconds())
+}
+
+// render formats every recorded metric in Prometheus text exposition
+// format.
+func (m *metrics) render() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.sources))
+	for name := range m.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	b.WriteString("# HELP sunlapse_captures_attempted_total Total image captures attempted.\n")
+	b.WriteString("# TYPE sunlapse_captures_attempted_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "sunlapse_captures_attempted_total{source=%q} %d\n", name, m.sources[name].capturesAttempted)
+	}
+
+	b.WriteString("# HELP sunlapse_captures_succeeded_total Total image captures that succeeded.\n")
+	b.WriteString("# TYPE sunlapse_captures_succeeded_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "sunlapse_captures_succeeded_total{source=%q} %d\n", name, m.sources[name].capturesSucceeded)
+	}
+
+	b.WriteString("# HELP sunlapse_captures_failed_total Total image captures that failed.\n")
+	b.WriteString("# TYPE sunlapse_captures_failed_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "sunlapse_captures_failed_total{source=%q} %d\n", name, m.sources[name].capturesFailed)
+	}
+
+	b.WriteString("# HELP sunlapse_upload_bytes_total Total bytes uploaded through the configured Storage backend.\n")
+	b.WriteString("# TYPE sunlapse_upload_bytes_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "sunlapse_upload_bytes_total{source=%q} %d\n", name, m.sources[name].uploadBytes)
+	}
+
+	b.WriteString("# HELP sunlapse_daytime Whether the source is currently within a capture window.\n")
+	b.WriteString("# TYPE sunlapse_daytime gauge\n")
+	for _, name := range names {
+		value := 0
+		if status, ok := getStatus(name); ok && status.IsDaytime {
+			value = 1
+		}
+
+		fmt.Fprintf(&b, "sunlapse_daytime{source=%q} %d\n", name, value)
+	}
+
+	b.WriteString("# HELP sunlapse_ffmpeg_duration_seconds Time taken to render each source's timelapse video.\n")
+	b.WriteString("# TYPE sunlapse_ffmpeg_duration_seconds histogram\n")
+	for _, name := range names {
+		renderHistogram(&b, name, m.sources[name].ffmpegDurations)
+	}
+
+	return b.String()
+}
+
+// renderHistogram writes samples as a Prometheus histogram for source,
+// bucketed by ffmpegDurationBuckets.
+func renderHistogram(b *strings.Builder, source string, samples []float64) {
+	sum := 0.0
+	counts := make([]int, len(ffmpegDurationBuckets))
+
+	for _, sample := range samples {
+		sum += sample
+
+		for i, le := range ffmpegDurationBuckets {
+			if sample <= le {
+				counts[i]++
+			}
+		}
+	}
+
+	for i, le := range ffmpegDurationBuckets {
+		fmt.Fprintf(b, "sunlapse_ffmpeg_duration_seconds_bucket{source=%q,le=\"%g\"} %d\n", source, le, counts[i])
+	}
+
+	fmt.Fprintf(b, "sunlapse_ffmpeg_duration_seconds_bucket{source=%q,le=\"+Inf\"} %d\n", source, len(samples))
+	fmt.Fprintf(b, "sunlapse_ffmpeg_duration_seconds_sum{source=%q} %g\n", source, sum)
+	fmt.Fprintf(b, "sunlapse_ffmpeg_duration_seconds_count{source=%q} %d\n", source, len(samples))
+}
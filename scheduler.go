@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// window is a single daily capture interval, e.g. sunrise to sunset.
+type window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Scheduler computes the capture windows for a day at a fixed location,
+// according to a configured schedule mode.
+type Scheduler struct {
+	// Mode selects how Windows computes its daily intervals: "civil",
+	// "nautical", and "astronomical" solve for the times the sun crosses
+	// the corresponding twilight altitude; "golden-hour" produces two
+	// short windows around sunrise and sunset; "manual" ignores the sun
+	// entirely and uses Start/End instead. Empty defaults to "civil".
+	Mode string
+
+	Latitude  float64
+	Longitude float64
+	Offset    float64
+
+	// Start and End are "HH:MM" strings used only in "manual" mode.
+	Start string
+	End   string
+}
+
+// Windows computes the capture windows for date, according to the
+// Scheduler's configured Mode.
+// It returns the computed windows and any errors encountered.
+func (s *Scheduler) Windows(date time.Time) ([]window, error) {
+	switch s.Mode {
+	case "", "civil":
+		return s.altitudeWindow(date, -6.0)
+	case "nautical":
+		return s.altitudeWindow(date, -12.0)
+	case "astronomical":
+		return s.altitudeWindow(date, -18.0)
+	case "golden-hour":
+		return s.goldenHourWindows(date)
+	case "manual":
+		return s.manualWindow(date)
+	default:
+		return nil, fmt.Errorf("unknown schedule mode: %s", s.Mode)
+	}
+}
+
+// altitudeWindow returns a single window spanning from the morning to the
+// evening crossing of altitude degrees.
+func (s *Scheduler) altitudeWindow(date time.Time, altitude float64) ([]window, error) {
+	rise, set, err := altitudeCrossings(s.Latitude, s.Longitude, s.Offset, altitude, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return []window{{Start: rise, End: set}}, nil
+}
+
+// goldenHourWindows returns the two short windows, around sunrise and
+// sunset, during which the sun sits between -4 and +6 degrees of altitude.
+func (s *Scheduler) goldenHourWindows(date time.Time) ([]window, error) {
+	lowRise, lowSet, err := altitudeCrossings(s.Latitude, s.Longitude, s.Offset, -4.0, date)
+	if err != nil {
+		return nil, err
+	}
+
+	highRise, highSet, err := altitudeCrossings(s.Latitude, s.Longitude, s.Offset, 6.0, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return []window{
+		{Start: lowRise, End: highRise},
+		{Start: highSet, End: lowSet},
+	}, nil
+}
+
+// manualWindow returns a single window built from Start and End, applied to
+// date, ignoring the sun entirely.
+func (s *Scheduler) manualWindow(date time.Time) ([]window, error) {
+	start, err := parseClock(date, s.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manual start time: %w", err)
+	}
+
+	end, err := parseClock(date, s.End)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manual end time: %w", err)
+	}
+
+	return []window{{Start: start, End: end}}, nil
+}
+
+// parseClock parses an "HH:MM" string and applies it to date's year, month,
+// and day.
+func parseClock(date time.Time, clock string) (time.Time, error) {
+	parsed, err := time.ParseInLocation("15:04", clock, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(date.Year(), date.Month(), date.Day(),
+		parsed.Hour(), parsed.Minute(), 0, 0, time.Local), nil
+}
+
+// inWindows reports whether check falls within any of windows.
+func inWindows(windows []window, check time.Time) bool {
+	for _, w := range windows {
+		if check.After(w.Start) && check.Before(w.End) {
+			return true
+		}
+	}
+
+	return false
+}
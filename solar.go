@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// julianEpoch is 1899-12-30, the epoch used by the NOAA solar position
+// algorithm below; its Julian day number is 2415018.5.
+var julianEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// solarPosition holds the values needed to compute when the sun crosses a
+// given altitude on a given day, for a given location.
+type solarPosition struct {
+	declination    float64 // degrees
+	equationOfTime float64 // minutes
+}
+
+// calcSolarPosition computes the sun's declination and the equation of time
+// for date, following the NOAA solar calculator algorithm (the same one
+// github.com/kelvins/sunrisesunset uses internally for its fixed -0.833
+// degree sunrise/sunset calculation).
+func calcSolarPosition(date time.Time) solarPosition {
+	numDays := date.Sub(julianEpoch).Hours() / 24.0
+
+	julianDay := numDays + 2415018.5
+	julianCentury := (julianDay - 2451545.0) / 36525.0
+
+	geomMeanLongSun := math.Mod(280.46646+julianCentury*(36000.76983+julianCentury*0.0003032), 360.0)
+	geomMeanAnomSun := 357.52911 + julianCentury*(35999.05029-0.0001537*julianCentury)
+	eccentEarthOrbit := 0.016708634 - julianCentury*(0.000042037+0.0000001267*julianCentury)
+
+	sunEqCtr := math.Sin(deg2rad(geomMeanAnomSun))*(1.914602-julianCentury*(0.004817+0.000014*julianCentury)) +
+		math.Sin(deg2rad(2*geomMeanAnomSun))*(0.019993-0.000101*julianCentury) +
+		math.Sin(deg2rad(3*geomMeanAnomSun))*0.000289
+
+	sunTrueLong := sunEqCtr + geomMeanLongSun
+	sunAppLong := sunTrueLong - 0.00569 - 0.00478*math.Sin(deg2rad(125.04-1934.136*julianCentury))
+
+	meanObliqEcliptic := 23.0 + (26.0+(21.448-julianCentury*(46.815+julianCentury*(0.00059-julianCentury*0.001813)))/60.0)/60.0
+	obliqCorr := meanObliqEcliptic + 0.00256*math.Cos(deg2rad(125.04-1934.136*julianCentury))
+
+	declination := rad2deg(math.Asin(math.Sin(deg2rad(obliqCorr)) * math.Sin(deg2rad(sunAppLong))))
+
+	multiFactor := math.Tan(deg2rad(obliqCorr/2.0)) * math.Tan(deg2rad(obliqCorr/2.0))
+
+	equationOfTime := 4.0 * rad2deg(
+		multiFactor*math.Sin(2.0*deg2rad(geomMeanLongSun))-
+			2.0*eccentEarthOrbit*math.Sin(deg2rad(geomMeanAnomSun))+
+			4.0*eccentEarthOrbit*multiFactor*math.Sin(deg2rad(geomMeanAnomSun))*math.Cos(2.0*deg2rad(geomMeanLongSun))-
+			0.5*multiFactor*multiFactor*math.Sin(4.0*deg2rad(geomMeanLongSun))-
+			1.25*eccentEarthOrbit*eccentEarthOrbit*math.Sin(2.0*deg2rad(geomMeanAnomSun)))
+
+	return solarPosition{declination: declination, equationOfTime: equationOfTime}
+}
+
+// altitudeCrossings computes the two times (morning and evening) the sun's
+// center crosses the given altitude, in degrees, negative below the
+// horizon, on date at the given location.
+// It returns an error if the sun does not cross that altitude on this day,
+// staying above or below it all day, e.g. midnight sun or polar night near
+// the poles.
+func altitudeCrossings(lat, long, utcOffset, altitude float64, date time.Time) (time.Time, time.Time, error) {
+	pos := calcSolarPosition(date)
+
+	cosHourAngle := math.Cos(deg2rad(90.0-altitude))/(math.Cos(deg2rad(lat))*math.Cos(deg2rad(pos.declination))) -
+		math.Tan(deg2rad(lat))*math.Tan(deg2rad(pos.declination))
+	if cosHourAngle < -1.0 || cosHourAngle > 1.0 {
+		return time.Time{}, time.Time{},
+			errors.New("sun does not cross the requested altitude on this day at this latitude")
+	}
+
+	hourAngle := rad2deg(math.Acos(cosHourAngle))
+
+	solarNoonSeconds := (720.0 - 4.0*long - pos.equationOfTime + utcOffset*60.0) * 60.0
+
+	riseSeconds := int(solarNoonSeconds - hourAngle*4.0*60.0)
+	setSeconds := int(solarNoonSeconds + hourAngle*4.0*60.0)
+
+	rise := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, riseSeconds, 0, time.Local)
+	set := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, setSeconds, 0, time.Local)
+
+	return rise, set, nil
+}
+
+// deg2rad converts degrees to radians.
+func deg2rad(degrees float64) float64 {
+	return degrees * (math.Pi / 180.0)
+}
+
+// rad2deg converts radians to degrees.
+func rad2deg(radians float64) float64 {
+	return radians * (180.0 / math.Pi)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Source represents a single configured webcam to capture images from. A
+// deployment with more than one camera configures one Source per camera;
+// each gets its own capture goroutine and its own images, video, and
+// archive, named after it.
+type Source struct {
+	// Name identifies the source, and is used as the directory name under
+	// which its images, videos, and archives are stored.
+	Name string `json:"name" yaml:"name"`
+	// URL is the HTTP(S) endpoint images are fetched from. In MJPEG mode,
+	// this is the stream URL rather than a single-image endpoint.
+	URL string `json:"url" yaml:"url"`
+	// Username and Password, if set, are sent as HTTP basic auth.
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// Headers are added to every outgoing request, e.g. for an API key.
+	Headers map[string]string `json:"headers" yaml:"headers"`
+	// Period overrides SUNLAPSE_PERIOD for this source alone. Zero uses the
+	// global default.
+	Period int `json:"period" yaml:"period"`
+	// Format hints at the image file extension to save and encode with,
+	// e.g. "jpg" or "png". Defaults to "jpg".
+	Format string `json:"format" yaml:"format"`
+	// MJPEG treats URL as a multipart/x-mixed-replace stream and grabs a
+	// single frame per tick, rather than issuing a full GET per tick.
+	MJPEG bool `json:"mjpeg" yaml:"mjpeg"`
+}
+
+// sourcesFile is the shape of the YAML/JSON document loaded from
+// SUNLAPSE_SOURCESFILE.
+type sourcesFile struct {
+	Sources []Source `json:"sources" yaml:"sources"`
+}
+
+// loadSources reads the capture Source configuration from path, which may
+// be JSON or YAML depending on its file extension. If path does not exist,
+// a single Source named "default" is synthesized from the legacy
+// SUNLAPSE_ENDPOINT variable, so single-camera deployments do not need a
+// sources file.
+// It returns the configured Sources and any errors encountered.
+func loadSources(path string) ([]Source, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		if conf.Endpoint == "" {
+			return nil, errors.New("no sources file found and SUNLAPSE_ENDPOINT is not set")
+		}
+
+		return []Source{{Name: "default", URL: conf.Endpoint}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var parsed sourcesFile
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, &parsed)
+	} else {
+		err = yaml.Unmarshal(data, &parsed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parsed.Sources) == 0 {
+		return nil, errors.New("no sources configured")
+	}
+
+	return parsed.Sources, nil
+}
+
+// findSource looks up a configured Source by name.
+// It returns the Source and whether it was found.
+func findSource(name string) (Source, bool) {
+	for _, source := range sources {
+		if source.Name == name {
+			return source, true
+		}
+	}
+
+	return Source{}, false
+}
+
+// newSourceRequest builds an HTTP GET request for source, applying its
+// configured basic auth and headers.
+// It returns the request and any errors encountered while building it.
+func newSourceRequest(source Source) (*http.Request, error) {
+	request, err := http.NewRequest(http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if source.Username != "" || source.Password != "" {
+		request.SetBasicAuth(source.Username, source.Password)
+	}
+
+	for key, value := range source.Headers {
+		request.Header.Set(key, value)
+	}
+
+	return request, nil
+}
+
+// getImage retrieves a single image from source, using MJPEG frame grabbing
+// if source.MJPEG is set.
+// It returns a byte slice with the image contents and any errors encountered.
+func getImage(source Source) ([]byte, error) {
+	if source.MJPEG {
+		return getMJPEGFrame(source)
+	}
+
+	cLog := log.WithFields(log.Fields{
+		"source": source.Name,
+		"url":    source.URL,
+	})
+
+	// Byte slice that will eventually hold the image contents
+	var image []byte
+
+	// Create an HTTP request based on the source's configured URL, auth, and
+	// headers, returning an error if the request cannot be created.
+	request, err := newSourceRequest(source)
+	if err != nil {
+		cLog.Error(err)
+		return image, err
+	}
+
+	// Make the HTTP request with the shared http Client, returning an error if
+	// the request fails or times out.
+	response, err := client.Do(request)
+	if err != nil {
+		cLog.Error(err)
+		return image, err
+	}
+	defer response.Body.Close()
+
+	// Check if the status code is OK (200) and return an error if it is not.
+	if response.StatusCode != http.StatusOK {
+		cLog.Errorf("non-200 status code received: %d", response.StatusCode)
+		return image, errors.New("non-200 status code received")
+	}
+
+	// Parse the response body into a byte slice, returning an error if unable to
+	// parse.
+	image, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		cLog.Error(err)
+		return image, err
+	}
+
+	// Return the byte slice.
+	return image, nil
+}
+
+// getMJPEGFrame connects to source's MJPEG stream and reads a single
+// multipart/x-mixed-replace frame, closing the connection once it has one
+// rather than holding the stream open between ticks.
+// It returns a byte slice with the frame contents and any errors encountered.
+func getMJPEGFrame(source Source) ([]byte, error) {
+	cLog := log.WithFields(log.Fields{
+		"source": source.Name,
+		"url":    source.URL,
+	})
+
+	request, err := newSourceRequest(source)
+	if err != nil {
+		cLog.Error(err)
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		cLog.Error(err)
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		cLog.Errorf("non-200 status code received: %d", response.StatusCode)
+		return nil, errors.New("non-200 status code received")
+	}
+
+	_, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil {
+		cLog.Error(err)
+		return nil, err
+	}
+
+	if params["boundary"] == "" {
+		return nil, errors.New("mjpeg stream did not provide a multipart boundary")
+	}
+
+	reader := multipart.NewReader(response.Body, params["boundary"])
+
+	part, err := reader.NextPart()
+	if err != nil {
+		cLog.Error(err)
+		return nil, err
+	}
+	defer part.Close()
+
+	return ioutil.ReadAll(part)
+}
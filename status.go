@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// sourceStatus is a snapshot of a single Source's current schedule and
+// capture state, kept up to date by its captureLoop and runSummaryWorker,
+// and exposed read-only through the HTTP status API.
+type sourceStatus struct {
+	Mode        string    `json:"mode"`
+	Windows     []window  `json:"windows"`
+	IsDaytime   bool      `json:"is_daytime"`
+	ImageIndex  int       `json:"image_index"`
+	LastCapture time.Time `json:"last_capture,omitempty"`
+	LastSummary time.Time `json:"last_summary,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// statuses holds the current sourceStatus for every configured Source,
+// keyed by name.
+var (
+	statusMu sync.RWMutex
+	statuses = map[string]*sourceStatus{}
+)
+
+// getStatus returns a copy of source's current status.
+// It returns false if no status has been recorded for source yet.
+func getStatus(source string) (sourceStatus, bool) {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+
+	status, ok := statuses[source]
+	if !ok {
+		return sourceStatus{}, false
+	}
+
+	return *status, true
+}
+
+// updateStatus applies fn to source's status, creating it if this is the
+// first update recorded for source.
+func updateStatus(source string, fn func(*sourceStatus)) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	status, ok := statuses[source]
+	if !ok {
+		status = &sourceStatus{}
+		statuses[source] = status
+	}
+
+	fn(status)
+}
+
+// sourceControl holds the channels used to remotely control a running
+// captureLoop for a single Source, from the HTTP API.
+type sourceControl struct {
+	// capture requests an immediate out-of-band frame grab.
+	capture chan struct{}
+	// summarize requests the video/archive/upload pipeline run for the
+	// given date, as if that date's last capture window had just ended.
+	summarize chan time.Time
+}
+
+// controls holds each configured Source's sourceControl, keyed by name, so
+// the HTTP API can reach its running captureLoop.
+var controls = map[string]*sourceControl{}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Storage is implemented by each backend that sunlapse can archive finished
+// timelapse videos and image archives to. Callers group related files under
+// a bucket, e.g. "<source>/<date>", rather than a single flat namespace.
+type Storage interface {
+	// Put writes the contents of r, of size bytes and the given contentType,
+	// to name within bucket.
+	Put(bucket, name string, r io.Reader, contentType string, size int64) error
+	// Get retrieves the contents of name within bucket.
+	Get(bucket, name string) (io.ReadCloser, error)
+	// Delete removes name within bucket.
+	Delete(bucket, name string) error
+	// Type returns the backend's identifier, e.g. "drive", "local", or "s3".
+	Type() string
+}
+
+// getStorage constructs the Storage backend selected by the SUNLAPSE_STORAGE
+// environment variable.
+// It returns a Storage and any errors encountered during construction.
+func getStorage() (Storage, error) {
+	switch conf.Storage {
+	case "drive":
+		return newDriveStorage()
+	case "local":
+		return newLocalStorage(conf.LocalStorePath), nil
+	case "s3":
+		return newS3Storage(conf.S3Bucket, conf.S3Region)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", conf.Storage)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// localStorage implements Storage by copying files into a directory on the
+// local filesystem, for users who do not want to offload timelapses to a
+// remote provider.
+type localStorage struct {
+	basePath string
+}
+
+// newLocalStorage returns a localStorage rooted at basePath.
+func newLocalStorage(basePath string) *localStorage {
+	return &localStorage{basePath: basePath}
+}
+
+// Put writes r to <basePath>/<bucket>/<name>, creating the bucket directory
+// if it does not already exist.
+// It returns any errors encountered during the write.
+func (s *localStorage) Put(bucket, name string, r io.Reader, contentType string, size int64) error {
+	dir := fmt.Sprintf("%s/%s", s.basePath, bucket)
+
+	if err := createDirectory(dir); err != nil {
+		return err
+	}
+
+	file, err := os.Create(fmt.Sprintf("%s/%s", dir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, r)
+	return err
+}
+
+// Get opens <basePath>/<bucket>/<name> for reading.
+// It returns an io.ReadCloser and any errors encountered.
+func (s *localStorage) Get(bucket, name string) (io.ReadCloser, error) {
+	return os.Open(fmt.Sprintf("%s/%s/%s", s.basePath, bucket, name))
+}
+
+// Delete removes <basePath>/<bucket>/<name>.
+// It returns any errors encountered during removal.
+func (s *localStorage) Delete(bucket, name string) error {
+	return os.Remove(fmt.Sprintf("%s/%s/%s", s.basePath, bucket, name))
+}
+
+// Type returns "local".
+func (s *localStorage) Type() string {
+	return "local"
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// s3Storage implements Storage via the AWS S3 API, for users who want to
+// offload timelapses to durable object storage without a Google account.
+type s3Storage struct {
+	bucket   string
+	client   *s3.S3
+	uploader *s3manager.Uploader
+}
+
+// newS3Storage returns an s3Storage that writes into the given S3 bucket in
+// region.
+// It returns an error if the AWS session cannot be established.
+func newS3Storage(bucket, region string) (*s3Storage, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Storage{
+		bucket:   bucket,
+		client:   s3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+// key joins bucket and name into the S3 object key, since sunlapse's logical
+// buckets are folders within a single S3 bucket rather than separate S3
+// buckets.
+func (s *s3Storage) key(bucket, name string) string {
+	return fmt.Sprintf("%s/%s", bucket, name)
+}
+
+// Put uploads r as an S3 object under bucket/name.
+// It returns any errors encountered during upload.
+func (s *s3Storage) Put(bucket, name string, r io.Reader, contentType string, size int64) error {
+	_, err := s.uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key(bucket, name)),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// Get retrieves the S3 object stored under bucket/name.
+// It returns an io.ReadCloser and any errors encountered.
+func (s *s3Storage) Get(bucket, name string) (io.ReadCloser, error) {
+	output, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(bucket, name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Body, nil
+}
+
+// Delete removes the S3 object stored under bucket/name.
+// It returns any errors encountered during removal.
+func (s *s3Storage) Delete(bucket, name string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(bucket, name)),
+	})
+	return err
+}
+
+// Type returns "s3".
+func (s *s3Storage) Type() string {
+	return "s3"
+}